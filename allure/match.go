@@ -0,0 +1,117 @@
+package allure
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"cli/log"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// matcher filters artifact paths by glob include/exclude patterns, matched
+// against the same "/"-joined relative path downloadFile reconstructs from a
+// fileID (e.g. "report/allure-results/results.json").
+type matcher struct {
+	include []string
+	exclude []string
+}
+
+// newMatcher builds a matcher once per GetArtifacts call so every descent
+// and enqueue decision reuses the same pattern set.
+func newMatcher(include, exclude []string) *matcher {
+	if len(include) == 0 && len(exclude) == 0 {
+		return nil
+	}
+	return &matcher{include: include, exclude: exclude}
+}
+
+// Allows reports whether a leaf file's path passes the include/exclude
+// filters. A nil matcher allows everything.
+func (m *matcher) Allows(relPath string) bool {
+	if m == nil {
+		return true
+	}
+	for _, pattern := range m.exclude {
+		if ok, _ := doublestar.Match(pattern, relPath); ok {
+			return false
+		}
+	}
+	if len(m.include) == 0 {
+		return true
+	}
+	for _, pattern := range m.include {
+		if ok, _ := doublestar.Match(pattern, relPath); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// MayDescend reports whether folderPath could still contain a selected file,
+// so getFoldersRecursively can prune whole subtrees before listing them.
+func (m *matcher) MayDescend(folderPath string) bool {
+	if m == nil {
+		return true
+	}
+	for _, pattern := range m.exclude {
+		if ok, _ := doublestar.Match(pattern, folderPath); ok {
+			return false
+		}
+	}
+	if len(m.include) == 0 {
+		return true
+	}
+	for _, pattern := range m.include {
+		prefix := literalPrefix(pattern)
+		if prefix == "" || folderPath == prefix || strings.HasPrefix(folderPath, prefix+"/") || strings.HasPrefix(prefix, folderPath+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// literalPrefix returns the directories of pattern that precede its first
+// wildcard, e.g. "report/allure-results/**" -> "report/allure-results".
+func literalPrefix(pattern string) string {
+	idx := strings.IndexAny(pattern, "*?[")
+	if idx == -1 {
+		return pattern
+	}
+	prefix := pattern[:idx]
+	if slash := strings.LastIndex(prefix, "/"); slash != -1 {
+		return prefix[:slash]
+	}
+	return ""
+}
+
+// selectionDigest hashes the sorted set of selected paths so a resumed run
+// with the same --include/--exclude filters can tell whether the selection
+// itself drifted (new/removed files on the server) since the last run.
+func selectionDigest(paths []string) string {
+	sorted := append([]string(nil), paths...)
+	sort.Strings(sorted)
+	h := sha256.New()
+	for _, p := range sorted {
+		h.Write([]byte(p))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// checkSelectionDrift compares digest against the one recorded for the
+// previous run in whereToSave and warns if the selected file set changed,
+// then persists digest for the next run.
+func checkSelectionDrift(whereToSave string, digest string) {
+	digestPath := filepath.Join(whereToSave, ".allure-selection.sha256")
+	if prev, err := os.ReadFile(digestPath); err == nil && string(prev) != digest {
+		log.Warn().Str("run_dir", whereToSave).Msg("artifact selection changed since the last resumed run")
+	}
+	if err := os.WriteFile(digestPath, []byte(digest), 0644); err != nil {
+		log.Warn().Err(err).Str("run_dir", whereToSave).Msg("failed to persist selection digest")
+	}
+}
@@ -0,0 +1,85 @@
+package allure
+
+import "testing"
+
+func TestMatcherMayDescend(t *testing.T) {
+	cases := []struct {
+		name    string
+		include []string
+		exclude []string
+		folder  string
+		want    bool
+	}{
+		{
+			name:    "folder matching the excluded prefix exactly is pruned",
+			exclude: []string{"logs/**"},
+			folder:  "logs",
+			want:    false,
+		},
+		{
+			name:    "folder under the excluded prefix is pruned",
+			exclude: []string{"logs/**"},
+			folder:  "logs/internal",
+			want:    false,
+		},
+		{
+			name:    "folder on the path down to an included prefix is allowed",
+			include: []string{"report/allure-results/**"},
+			folder:  "report",
+			want:    true,
+		},
+		{
+			name:    "folder that shares a string prefix with an included pattern but diverges at the next segment is pruned",
+			include: []string{"report-final/**"},
+			folder:  "report",
+			want:    false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := newMatcher(tc.include, tc.exclude)
+			if got := m.MayDescend(tc.folder); got != tc.want {
+				t.Errorf("MayDescend(%q) = %v, want %v", tc.folder, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMatcherAllows(t *testing.T) {
+	cases := []struct {
+		name    string
+		include []string
+		exclude []string
+		path    string
+		want    bool
+	}{
+		{
+			name: "nil matcher allows everything",
+			path: "report/allure-results/results.json",
+			want: true,
+		},
+		{
+			name:    "exclude wins over include",
+			include: []string{"**/*.json"},
+			exclude: []string{"report/allure-results/**"},
+			path:    "report/allure-results/results.json",
+			want:    false,
+		},
+		{
+			name:    "include filters to matching files only",
+			include: []string{"**/*.json"},
+			path:    "report/allure-results/results.txt",
+			want:    false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := newMatcher(tc.include, tc.exclude)
+			if got := m.Allows(tc.path); got != tc.want {
+				t.Errorf("Allows(%q) = %v, want %v", tc.path, got, tc.want)
+			}
+		})
+	}
+}
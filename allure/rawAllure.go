@@ -1,12 +1,17 @@
 package allure
 
 import (
+	"cli/log"
 	"cli/request"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/cheggaaa/pb/v3"
 	"github.com/otiai10/copy"
 	"io"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"path"
 	"path/filepath"
@@ -23,45 +28,178 @@ type ArtifactTree struct {
 
 var maxConcurrentDownloads = 5 // Limit the number of concurrent downloads.
 
-func GetArtifacts(token string, runId string, whereToSave string) {
-	rootFolders := getFolder(token, runId)
+// Options controls how GetArtifacts downloads a run's artifacts. It's
+// threaded through from config rather than stored globally so concurrent
+// runs (tests, callers embedding the package) don't stomp on each other.
+type Options struct {
+	NoProgress bool     // disable the progress bar entirely
+	Silent     bool     // suppress all non-error output, including the bar
+	Force      bool     // bypass the local size/ETag cache and re-download everything
+	Include    []string // glob patterns a file's relative path must match at least one of to be downloaded
+	Exclude    []string // glob patterns that prune a file or folder regardless of Include
+}
+
+// countingReader wraps an io.Reader and reports every read to a progress bar,
+// letting us drive bar updates straight from the copy in downloadFile instead
+// of guessing at completion after the fact.
+type countingReader struct {
+	reader io.Reader
+	bar    *pb.ProgressBar
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	if n > 0 && c.bar != nil {
+		c.bar.Add(n)
+	}
+	return n, err
+}
+
+// GetArtifacts downloads every selected artifact for runId into whereToSave.
+// It returns an aggregate of every download failure (joined with
+// errors.Join), so callers can branch on errors.Is against the sentinel
+// errors in the request package rather than only ever seeing an opaque
+// failure.
+func GetArtifacts(ctx context.Context, token string, runId string, whereToSave string, opts Options) error {
+	rootFolders, err := getFolder(ctx, token, runId)
+	if err != nil {
+		log.Error().Err(err).Str("run_id", runId).Msg("failed to retrieve root folders")
+		return fmt.Errorf("retrieving root folders for run %s: %w", runId, err)
+	}
 	if rootFolders == nil || len(*rootFolders) == 0 {
-		fmt.Println("Failed to retrieve root folders.")
-		return
+		return fmt.Errorf("retrieving root folders for run %s: %w", runId, request.ErrArtifactNotFound)
+	}
+
+	filter := newMatcher(opts.Include, opts.Exclude)
+
+	fileIDs := make([]string, 0)
+	var totalBytes int64
+	for _, folder := range *rootFolders {
+		ids, bytes := enumerateFiles(ctx, token, folder.ID, filter)
+		fileIDs = append(fileIDs, ids...)
+		totalBytes += bytes
+	}
+	checkSelectionDrift(whereToSave, selectionDigest(fileIDs))
+
+	var bar *pb.ProgressBar
+	if !opts.NoProgress && !opts.Silent {
+		bar = pb.Full.Start64(totalBytes)
+		bar.Set(pb.Bytes, true)
+		bar.SetRefreshRate(250 * time.Millisecond)
+		defer bar.Finish()
 	}
 
 	// Semaphore to limit concurrent downloads
 	sem := make(chan struct{}, maxConcurrentDownloads)
 	// Channel to capture errors from goroutines
-	errors := make(chan error)
+	downloadErrors := make(chan error)
 
 	// Counter to wait for all goroutines to finish
 	var wg sync.WaitGroup
 
-	// Start a goroutine to continuously read and handle errors
+	// Start a goroutine to continuously read and handle errors, collecting
+	// them so they can be joined into GetArtifacts' return value once every
+	// download finishes.
+	var downloadErrs []error
+	drained := make(chan struct{})
 	go func() {
-		for err := range errors {
+		defer close(drained)
+		for err := range downloadErrors {
 			if err != nil {
-				fmt.Println("Error during download:", err)
+				log.Error().Err(err).Str("run_id", runId).Msg("artifact download failed")
+				downloadErrs = append(downloadErrs, err)
 			}
 		}
 	}()
 
 	for _, folder := range *rootFolders {
-		getFoldersRecursively(token, folder.ID, whereToSave, sem, errors, &wg)
+		getFoldersRecursively(ctx, token, folder.ID, whereToSave, sem, downloadErrors, &wg, bar, opts.Force, filter)
 	}
 
-	// Wait for all goroutines to finish
+	// Wait for all goroutines to finish, or for cancellation to drain them.
 	wg.Wait()
-	close(errors)
+	close(downloadErrors)
+	<-drained
+
+	if ctx.Err() != nil {
+		log.Warn().Err(ctx.Err()).Str("run_id", runId).Msg("download cancelled")
+		return errors.Join(downloadErrs...)
+	}
 
 	// Post-processing: move contents from runId folder to root
 	relocateContents(whereToSave, runId)
 	// Update Allure json paths
 	updateJsonPaths(whereToSave)
+	return errors.Join(downloadErrs...)
 }
 
-func getFolder(token string, folder string) *[]ArtifactTree {
+// enumerateFiles walks the folder tree rooted at folderID without downloading
+// anything, so GetArtifacts can size the progress bar before the first byte
+// is fetched. Folders pruned by filter are skipped entirely; files it
+// excludes are left out of the count.
+func enumerateFiles(ctx context.Context, token string, folderID string, filter *matcher) ([]string, int64) {
+	if !filter.MayDescend(folderID) {
+		return nil, 0
+	}
+	resp, err := request.SendGetRequest(ctx, "https://app.testwise.pro/api/v1/artifact/"+folderID, token)
+	if err != nil || resp == nil || resp.Body == nil {
+		log.Error().Err(err).Str("folder_id", folderID).Msg("failed to enumerate folder")
+		return nil, 0
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		log.Error().Err(err).Str("folder_id", folderID).Msg("failed to read enumeration response")
+		return nil, 0
+	}
+
+	var folders []ArtifactTree
+	if err := json.Unmarshal(bodyBytes, &folders); err != nil {
+		log.Error().Err(err).Str("folder_id", folderID).Msg("failed to unmarshal enumeration response")
+		return nil, 0
+	}
+
+	var ids []string
+	var total int64
+	for _, folder := range folders {
+		if folder.IsFile {
+			if !filter.Allows(folder.ID) {
+				continue
+			}
+			ids = append(ids, folder.ID)
+			total += headContentLength(ctx, token, folder.ID)
+		} else {
+			subIDs, subTotal := enumerateFiles(ctx, token, folder.ID, filter)
+			ids = append(ids, subIDs...)
+			total += subTotal
+		}
+	}
+	return ids, total
+}
+
+// headContentLength asks for a file's size without downloading its body. A
+// failed or missing Content-Length just means the bar under-estimates this
+// file's share of the total, which is preferable to aborting the whole run.
+func headContentLength(ctx context.Context, token string, fileID string) int64 {
+	validFileID := strings.ReplaceAll(fileID, "#", "%23")
+	resp, err := request.SendHeadRequest(ctx, "https://app.testwise.pro/api/v1/artifact?key="+validFileID, token)
+	if err != nil || resp == nil {
+		return 0
+	}
+	defer resp.Body.Close()
+	if resp.ContentLength > 0 {
+		return resp.ContentLength
+	}
+	return 0
+}
+
+// folderPollPacer paces the wait for expected subfolders to appear,
+// backing off the poll interval instead of sleeping a fixed 5s between
+// every attempt.
+var folderPollPacer = request.NewPacer(2*time.Second, 20*time.Second, 1, 8)
+
+func getFolder(ctx context.Context, token string, folder string) (*[]ArtifactTree, error) {
 	expectedFolders := map[string]bool{
 		"bill":        false,
 		"devices":     false,
@@ -74,12 +212,10 @@ func getFolder(token string, folder string) *[]ArtifactTree {
 
 	var lastRetrievedFolders []ArtifactTree
 
-	for i := 0; i < 5; i++ {
-		time.Sleep(5 * time.Second)
-
-		resp := request.SendGetRequest("https://app.testwise.pro/api/v1/artifact/"+folder, token)
-		if resp == nil || resp.Body == nil {
-			continue
+	err := folderPollPacer.Call(ctx, func() (bool, error) {
+		resp, err := request.SendGetRequest(ctx, "https://app.testwise.pro/api/v1/artifact/"+folder, token)
+		if err != nil || resp == nil || resp.Body == nil {
+			return true, fmt.Errorf("polling folder %s: %w", folder, err)
 		}
 
 		bodyBytes, _ := io.ReadAll(resp.Body)
@@ -87,6 +223,7 @@ func getFolder(token string, folder string) *[]ArtifactTree {
 
 		var folders []ArtifactTree
 		json.Unmarshal(bodyBytes, &folders)
+		lastRetrievedFolders = folders
 
 		// Update the status of found expected folders
 		for _, f := range folders {
@@ -96,65 +233,80 @@ func getFolder(token string, folder string) *[]ArtifactTree {
 		}
 
 		// Check if all expected folders are found
-		allFound := true
 		for _, found := range expectedFolders {
 			if !found {
-				allFound = false
-				break
+				return true, fmt.Errorf("not all expected folders present under %s yet", folder)
 			}
 		}
+		return false, nil
+	})
 
-		if allFound {
-			return &folders
-		}
+	return &lastRetrievedFolders, err
+}
 
-		// Store the last set of folders retrieved
-		lastRetrievedFolders = folders
+func getFoldersRecursively(ctx context.Context, token string, folderID string, whereToSave string, sem chan struct{}, downloadErrors chan<- error, wg *sync.WaitGroup, bar *pb.ProgressBar, force bool, filter *matcher) {
+	if ctx.Err() != nil {
+		return
+	}
+	if !filter.MayDescend(folderID) {
+		return
 	}
 
-	// If all expected folders aren't found after 3 attempts, return the last set of folders found
-	return &lastRetrievedFolders
-}
-
-func getFoldersRecursively(token string, folderID string, whereToSave string, sem chan struct{}, errors chan<- error, wg *sync.WaitGroup) {
-	resp := request.SendGetRequest("https://app.testwise.pro/api/v1/artifact/"+folderID, token)
+	resp, err := request.SendGetRequest(ctx, "https://app.testwise.pro/api/v1/artifact/"+folderID, token)
+	if err != nil || resp == nil || resp.Body == nil {
+		log.Error().Err(err).Str("folder_id", folderID).Msg("failed to list folder")
+		return
+	}
 	defer resp.Body.Close()
 
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		fmt.Println("Error reading response:", err.Error())
+		log.Error().Err(err).Str("folder_id", folderID).Msg("failed to read response")
 		return
 	}
 
 	var folders []ArtifactTree
 	err = json.Unmarshal(bodyBytes, &folders)
 	if err != nil {
-		fmt.Println("Failed to unmarshal response:", err.Error())
+		log.Error().Err(err).Str("folder_id", folderID).Msg("failed to unmarshal response")
 		return
 	}
 
 	for _, folder := range folders {
+		if ctx.Err() != nil {
+			return
+		}
 		if folder.IsFile {
+			if !filter.Allows(folder.ID) {
+				continue
+			}
 			wg.Add(1)
 			go func(id string) {
 				defer wg.Done()
-				sem <- struct{}{} // Acquire semaphore
-				err := downloadFile(token, id, whereToSave)
+				select {
+				case sem <- struct{}{}: // Acquire semaphore
+				case <-ctx.Done():
+					return
+				}
+				err := downloadFile(ctx, token, id, whereToSave, bar, force)
 				<-sem // Release semaphore
 				if err != nil {
-					errors <- err
+					downloadErrors <- err
 				}
 			}(folder.ID)
 		} else {
-			getFoldersRecursively(token, folder.ID, whereToSave, sem, errors, wg)
+			getFoldersRecursively(ctx, token, folder.ID, whereToSave, sem, downloadErrors, wg, bar, force, filter)
 		}
 	}
 }
 
-func downloadFile(token string, fileID string, whereToSave string) error {
+func downloadFile(ctx context.Context, token string, fileID string, whereToSave string, bar *pb.ProgressBar, force bool) error {
 	if fileID == "" {
 		return fmt.Errorf("empty fileID provided")
 	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
 
 	// Split the fileID path to figure out the folder structure and file name.
 	keyArray := strings.Split(fileID, "/")
@@ -166,47 +318,177 @@ func downloadFile(token string, fileID string, whereToSave string) error {
 	fileFolder := path.Join(whereToSave, subFolder)
 
 	// Ensure the directory structure exists.
-	err := os.MkdirAll(fileFolder, os.ModePerm)
-	if err != nil {
-		return fmt.Errorf("failed to create directory: %v", err)
+	if err := os.MkdirAll(fileFolder, os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
 	}
 
 	// Replace any '#' in the fileID with '%23' for the URL request. This is URL encoding.
 	validFileID := strings.ReplaceAll(fileID, "#", "%23")
-	resp := request.SendGetRequest("https://app.testwise.pro/api/v1/artifact?key="+validFileID, token)
+	url := "https://app.testwise.pro/api/v1/artifact?key=" + validFileID
+	filePath := path.Join(fileFolder, fileName)
+
+	// ctx only stops downloads that have not started yet: once started, a
+	// download runs to completion so a cancellation doesn't cut a file off
+	// mid-write.
+	return syncFile(context.WithoutCancel(ctx), token, url, filePath, bar, force)
+}
+
+// fileMeta is the sidecar record syncFile keeps alongside each downloaded
+// file, letting a rerun tell a genuinely changed artifact apart from one
+// that merely got re-uploaded with the same bytes.
+type fileMeta struct {
+	ETag string `json:"etag"`
+	Size int64  `json:"size"`
+}
+
+func metaPath(filePath string) string {
+	return filePath + ".meta.json"
+}
+
+func readFileMeta(filePath string) *fileMeta {
+	data, err := os.ReadFile(metaPath(filePath))
+	if err != nil {
+		return nil
+	}
+	var m fileMeta
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil
+	}
+	return &m
+}
+
+func writeFileMeta(filePath string, m fileMeta) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return
+	}
+	if err := os.WriteFile(metaPath(filePath), data, 0644); err != nil {
+		log.Warn().Err(err).Str("file", filePath).Msg("failed to write cache metadata")
+	}
+}
+
+// syncFile mirrors a single remote artifact to filePath: it skips the
+// download when the local copy's size and ETag already match the remote
+// HEAD, resumes a short local file with a ranged GET when a prior run's
+// recorded meta confirms the partial bytes belong to this same remote
+// version, and otherwise falls back to a full re-download.
+func syncFile(ctx context.Context, token string, url string, filePath string, bar *pb.ProgressBar, force bool) error {
+	head, err := request.SendHeadRequest(ctx, url, token)
+	if errors.Is(err, request.ErrArtifactNotFound) {
+		return fmt.Errorf("syncing %s: %w", filePath, err)
+	}
+	if err != nil {
+		log.Warn().Err(err).Str("url", url).Msg("HEAD check failed, falling back to full download")
+	}
+	var remoteSize int64
+	var remoteETag string
+	if head != nil {
+		head.Body.Close()
+		remoteSize = head.ContentLength
+		remoteETag = head.Header.Get("ETag")
+	}
+
+	if !force {
+		if localInfo, err := os.Stat(filePath); err == nil && remoteSize > 0 {
+			meta := readFileMeta(filePath)
+			switch {
+			case localInfo.Size() == remoteSize && meta != nil && remoteETag != "" && meta.ETag == remoteETag:
+				if bar != nil {
+					bar.Add64(remoteSize)
+				}
+				return nil
+			case localInfo.Size() < remoteSize && remoteETag != "" && meta != nil && meta.ETag == remoteETag:
+				if err := resumeFile(ctx, token, url, filePath, localInfo.Size(), bar); err != nil {
+					return err
+				}
+				writeFileMeta(filePath, fileMeta{ETag: remoteETag, Size: remoteSize})
+				return nil
+			}
+		}
+	}
+
+	if err := fetchFile(ctx, token, url, filePath, bar); err != nil {
+		return err
+	}
+	writeFileMeta(filePath, fileMeta{ETag: remoteETag, Size: remoteSize})
+	return nil
+}
+
+// fetchFile downloads url from scratch into filePath.
+func fetchFile(ctx context.Context, token string, url string, filePath string, bar *pb.ProgressBar) error {
+	resp, err := request.SendGetRequest(ctx, url, token)
+	if err != nil {
+		return fmt.Errorf("fetching %s: %w", filePath, err)
+	}
 	defer resp.Body.Close()
 
-	// Create the file at the determined path.
-	filePath := path.Join(fileFolder, fileName)
 	out, err := os.Create(filePath)
 	if err != nil {
-		return fmt.Errorf("got error while os.Create: %v", err)
+		return fmt.Errorf("got error while os.Create: %w", err)
 	}
 	defer out.Close()
 
-	// Copy the response body (the downloaded data) to our file.
-	_, err = io.Copy(out, resp.Body)
+	// Copy the response body (the downloaded data) to our file, reporting
+	// every chunk read to the progress bar as we go.
+	if _, err := io.Copy(out, progressReader(resp.Body, bar)); err != nil {
+		return fmt.Errorf("error writing file: %w", err)
+	}
+	return nil
+}
+
+// resumeFile appends the remainder of url, starting at fromByte, onto an
+// existing partial download.
+func resumeFile(ctx context.Context, token string, url string, filePath string, fromByte int64, bar *pb.ProgressBar) error {
+	resp, err := request.SendRangeGetRequest(ctx, url, token, fromByte)
+	if err != nil {
+		return fmt.Errorf("resuming %s: %w", filePath, err)
+	}
+
+	if resp.StatusCode != http.StatusPartialContent {
+		// The server didn't honor the Range request and is sending the full
+		// body from byte 0; appending it as-is would duplicate the bytes we
+		// already have. Discard the partial file and fetch it fresh instead.
+		resp.Body.Close()
+		if err := os.Truncate(filePath, 0); err != nil {
+			return fmt.Errorf("truncating partial %s before full refetch: %w", filePath, err)
+		}
+		return fetchFile(ctx, token, url, filePath, bar)
+	}
+	defer resp.Body.Close()
+
+	out, err := os.OpenFile(filePath, os.O_APPEND|os.O_WRONLY, 0644)
 	if err != nil {
-		return fmt.Errorf("error writing file: %v", err)
+		return fmt.Errorf("got error while opening file to resume: %w", err)
 	}
+	defer out.Close()
 
+	if _, err := io.Copy(out, progressReader(resp.Body, bar)); err != nil {
+		return fmt.Errorf("error resuming file: %w", err)
+	}
 	return nil
 }
 
+func progressReader(r io.Reader, bar *pb.ProgressBar) io.Reader {
+	if bar == nil {
+		return r
+	}
+	return &countingReader{reader: r, bar: bar}
+}
+
 func relocateContents(whereToSave string, runId string) {
 	runIdDir := filepath.Join(whereToSave, runId)
 	if _, err := os.Stat(runIdDir); os.IsNotExist(err) {
-		fmt.Println(runId, "directory does not exist. Skipping relocation.")
+		log.Debug().Str("run_id", runId).Msg("run directory does not exist, skipping relocation")
 		return
 	}
 	if err := copy.Copy(runIdDir, whereToSave); err != nil {
-		fmt.Println("Error copying files:", err)
+		log.Error().Err(err).Str("run_id", runId).Msg("failed to copy files during relocation")
 		return
 	}
 
 	// Remove the runId directory
 	if err := os.RemoveAll(runIdDir); err != nil {
-		fmt.Println("Error removing directory", runIdDir, ":", err)
+		log.Error().Err(err).Str("dir", runIdDir).Msg("failed to remove directory")
 	}
 }
 
@@ -227,7 +509,7 @@ func updateJsonPaths(whereToSave string) {
 	})
 
 	if err != nil {
-		fmt.Println("Error walking the path", whereToSave, ":", err)
+		log.Error().Err(err).Str("dir", whereToSave).Msg("failed to walk path")
 		return
 	}
 
@@ -235,7 +517,7 @@ func updateJsonPaths(whereToSave string) {
 	allureResultsDir := filepath.Join(whereToSave, "report", "allure-results")
 	files, err := ioutil.ReadDir(allureResultsDir)
 	if err != nil {
-		fmt.Println("Error reading directory", allureResultsDir, ":", err)
+		log.Error().Err(err).Str("dir", allureResultsDir).Msg("failed to read directory")
 		return
 	}
 
@@ -245,13 +527,13 @@ func updateJsonPaths(whereToSave string) {
 
 			data, err := ioutil.ReadFile(filePath)
 			if err != nil {
-				fmt.Println("Error reading file", filePath, ":", err)
+				log.Error().Err(err).Str("file", filePath).Msg("failed to read file")
 				continue
 			}
 
 			var jsonData map[string]interface{}
 			if err := json.Unmarshal(data, &jsonData); err != nil {
-				fmt.Println("Error unmarshaling JSON data from file", filePath, ":", err)
+				log.Error().Err(err).Str("file", filePath).Msg("failed to unmarshal JSON data")
 				continue
 			}
 
@@ -265,7 +547,7 @@ func updateJsonPaths(whereToSave string) {
 								if newPath, found := fileMap[filename]; found {
 									relativePath, err := filepath.Rel(allureResultsDir, newPath)
 									if err != nil {
-										fmt.Println("Error calculating relative path for", newPath, ":", err)
+										log.Error().Err(err).Str("path", newPath).Msg("failed to calculate relative path")
 										continue
 									}
 									attachMap["source"] = relativePath
@@ -277,12 +559,12 @@ func updateJsonPaths(whereToSave string) {
 
 				updatedData, err := json.MarshalIndent(jsonData, "", "  ")
 				if err != nil {
-					fmt.Println("Error marshaling JSON data for file", filePath, ":", err)
+					log.Error().Err(err).Str("file", filePath).Msg("failed to marshal JSON data")
 					continue
 				}
 
 				if err := ioutil.WriteFile(filePath, updatedData, 0644); err != nil {
-					fmt.Println("Error writing updated data to file", filePath, ":", err)
+					log.Error().Err(err).Str("file", filePath).Msg("failed to write updated JSON data")
 				}
 			}
 		}
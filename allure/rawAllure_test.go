@@ -0,0 +1,145 @@
+package allure
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// newSyncFileServer serves a fixed body for GET/HEAD, honoring Range
+// requests with 206 so resumeFile's ranged-GET path can be exercised.
+func newSyncFileServer(t *testing.T, body string, etag string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", etag)
+		if r.Method == http.MethodHead {
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if rng := r.Header.Get("Range"); rng != "" {
+			start, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(rng, "bytes="), "-"))
+			w.Header().Set("Content-Range", "bytes "+strconv.Itoa(start)+"-"+strconv.Itoa(len(body)-1)+"/"+strconv.Itoa(len(body)))
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)-start))
+			w.WriteHeader(http.StatusPartialContent)
+			_, _ = w.Write([]byte(body[start:]))
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestSyncFileSkipsWhenSizeAndMetaETagMatch(t *testing.T) {
+	const body = "hello artifact"
+	srv := newSyncFileServer(t, body, `"abc"`)
+	// Same size as body but different bytes, so a re-download would show.
+	local := strings.ToUpper(body)
+
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "artifact.txt")
+	if err := os.WriteFile(filePath, []byte(local), 0644); err != nil {
+		t.Fatal(err)
+	}
+	writeFileMeta(filePath, fileMeta{ETag: `"abc"`, Size: int64(len(body))})
+
+	if err := syncFile(context.Background(), "token", srv.URL, filePath, nil, false); err != nil {
+		t.Fatalf("syncFile returned error: %v", err)
+	}
+
+	got, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != local {
+		t.Fatalf("file content changed on a cache hit: got %q", got)
+	}
+}
+
+func TestSyncFileResumesWhenMetaETagMatchesPartialFile(t *testing.T) {
+	const body = "hello artifact, fully downloaded"
+	srv := newSyncFileServer(t, body, `"abc"`)
+
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "artifact.txt")
+	// Differs from the server's first bytes, so a full refetch would show.
+	partial := strings.ToUpper(body[:5])
+	want := partial + body[5:]
+	if err := os.WriteFile(filePath, []byte(partial), 0644); err != nil {
+		t.Fatal(err)
+	}
+	writeFileMeta(filePath, fileMeta{ETag: `"abc"`, Size: int64(len(body))})
+
+	if err := syncFile(context.Background(), "token", srv.URL, filePath, nil, false); err != nil {
+		t.Fatalf("syncFile returned error: %v", err)
+	}
+
+	got, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Fatalf("resumed file = %q, want %q", got, want)
+	}
+}
+
+func TestSyncFileRefetchesPartialFileWithoutRecordedMeta(t *testing.T) {
+	const body = "hello artifact, fully downloaded"
+	srv := newSyncFileServer(t, body, `"abc"`)
+
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "artifact.txt")
+	// A partial file with no sidecar meta, e.g. the previous attempt was
+	// interrupted before ever recording one. syncFile must not trust this
+	// partial prefix and should refetch from scratch instead of resuming.
+	// The partial bytes differ from the server's, so a resume would show.
+	if err := os.WriteFile(filePath, []byte(strings.ToUpper(body[:5])), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := syncFile(context.Background(), "token", srv.URL, filePath, nil, false); err != nil {
+		t.Fatalf("syncFile returned error: %v", err)
+	}
+
+	got, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != body {
+		t.Fatalf("refetched file = %q, want %q", got, body)
+	}
+}
+
+func TestSyncFileForceBypassesCache(t *testing.T) {
+	const body = "hello artifact"
+	srv := newSyncFileServer(t, body, `"abc"`)
+
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "artifact.txt")
+	// Same size and recorded ETag as the server's copy, so only force makes
+	// syncFile replace these bytes.
+	if err := os.WriteFile(filePath, []byte(strings.ToUpper(body)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	writeFileMeta(filePath, fileMeta{ETag: `"abc"`, Size: int64(len(body))})
+
+	if err := syncFile(context.Background(), "token", srv.URL, filePath, nil, true); err != nil {
+		t.Fatalf("syncFile returned error: %v", err)
+	}
+
+	got, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != body {
+		t.Fatalf("forced refetch produced wrong content: got %q", got)
+	}
+}
@@ -0,0 +1,145 @@
+// Package config parses cli's command-line flags (each with an environment
+// variable fallback) into a Config
+// that the rest of the program reads through Get* accessors, mirroring the
+// viper-style lookup-by-key pattern main.go already uses.
+package config
+
+import (
+	"flag"
+	"os"
+	"strings"
+)
+
+// Config holds every flag/env value resolved by ReadFlags. Accessors return
+// the zero value for an unknown key instead of panicking, so new keys can be
+// threaded through main.go ahead of the flag that sets them.
+type Config struct {
+	strings map[string]string
+	bools   map[string]bool
+	slices  map[string][]string
+}
+
+func (c *Config) GetString(key string) string {
+	return c.strings[key]
+}
+
+func (c *Config) GetBool(key string) bool {
+	return c.bools[key]
+}
+
+func (c *Config) GetStringSlice(key string) []string {
+	return c.slices[key]
+}
+
+var current *Config
+
+// GetConfig returns the Config built by the last call to ReadFlags.
+func GetConfig() *Config {
+	return current
+}
+
+// ReadFlags parses os.Args into a Config, falling back to the flag's
+// upper-case, underscore-separated environment variable (--api-key reads
+// API_KEY) for any flag left unset on the command line.
+func ReadFlags() error {
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+
+	host := fs.String("host", envOrDefault("HOST", "https://app.testwise.pro"), "testwise.pro API host")
+	login := fs.String("login", os.Getenv("LOGIN"), "testwise.pro login")
+	password := fs.String("password", os.Getenv("PASSWORD"), "testwise.pro password")
+	apiKey := fs.String("api-key", os.Getenv("API_KEY"), "testwise.pro API key (bypasses --login/--password)")
+	app := fs.String("app", os.Getenv("APP"), "path to the application under test")
+	testApp := fs.String("test-app", os.Getenv("TEST_APP"), "path to the test application")
+	name := fs.String("name", os.Getenv("NAME"), "commit/build name recorded for this run")
+	link := fs.String("link", os.Getenv("LINK"), "commit/build link recorded for this run")
+	allureOutput := fs.String("allure-output", os.Getenv("ALLURE_OUTPUT"), "directory to download allure artifacts into")
+	platform := fs.String("platform", os.Getenv("PLATFORM"), "target platform")
+	osVersion := fs.String("os-version", os.Getenv("OS_VERSION"), "OS version to run against")
+	isolated := fs.String("isolated", os.Getenv("ISOLATED"), "isolated run mode")
+	systemImage := fs.String("system-image", os.Getenv("SYSTEM_IMAGE"), "system image to run against")
+	noProgress := fs.Bool("no-progress", envOrBool("NO_PROGRESS"), "disable the artifact download progress bar")
+	silent := fs.Bool("silent", envOrBool("SILENT"), "suppress the progress bar and non-error logs")
+	force := fs.Bool("force", envOrBool("FORCE"), "re-download every artifact, ignoring the local size/ETag cache")
+	logFormat := fs.String("log-format", envOrDefault("LOG_FORMAT", "text"), "log output format: text|json")
+	logLevel := fs.String("log-level", envOrDefault("LOG_LEVEL", "info"), "log level: debug|info|warn|error")
+	include := stringSliceFlag{values: envSlice("INCLUDE")}
+	exclude := stringSliceFlag{values: envSlice("EXCLUDE")}
+	fs.Var(&include, "include", "glob pattern of artifact paths to include (repeatable)")
+	fs.Var(&exclude, "exclude", "glob pattern of artifact paths to exclude (repeatable)")
+
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		return err
+	}
+
+	current = &Config{
+		strings: map[string]string{
+			"HOST":          *host,
+			"LOGIN":         *login,
+			"PASSWORD":      *password,
+			"API_KEY":       *apiKey,
+			"APP":           *app,
+			"TEST_APP":      *testApp,
+			"NAME":          *name,
+			"LINK":          *link,
+			"ALLURE_OUTPUT": *allureOutput,
+			"PLATFORM":      *platform,
+			"OS_VERSION":    *osVersion,
+			"ISOLATED":      *isolated,
+			"SYSTEM_IMAGE":  *systemImage,
+			"LOG_FORMAT":    *logFormat,
+			"LOG_LEVEL":     *logLevel,
+		},
+		bools: map[string]bool{
+			"NO_PROGRESS": *noProgress,
+			"SILENT":      *silent,
+			"FORCE":       *force,
+		},
+		slices: map[string][]string{
+			"INCLUDE": include.values,
+			"EXCLUDE": exclude.values,
+		},
+	}
+	return nil
+}
+
+func envOrDefault(key string, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func envOrBool(key string) bool {
+	return os.Getenv(key) == "true"
+}
+
+func envSlice(key string) []string {
+	v := os.Getenv(key)
+	if v == "" {
+		return nil
+	}
+	return strings.Split(v, ",")
+}
+
+// stringSliceFlag implements flag.Value so --include/--exclude can be
+// repeated on the command line, each occurrence appending to the slice.
+// values starts out seeded from the env fallback; the first flag occurrence
+// discards that seed so a CLI flag fully replaces the env var instead of
+// appending to it, consistent with every other flag in this file.
+type stringSliceFlag struct {
+	values   []string
+	flagSeen bool
+}
+
+func (s *stringSliceFlag) String() string {
+	return strings.Join(s.values, ",")
+}
+
+func (s *stringSliceFlag) Set(v string) error {
+	if !s.flagSeen {
+		s.values = nil
+		s.flagSeen = true
+	}
+	s.values = append(s.values, v)
+	return nil
+}
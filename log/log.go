@@ -0,0 +1,48 @@
+// Package log provides the structured leveled logger used across the CLI,
+// replacing ad-hoc fmt.Println calls so run_id/file_id/attempt context
+// survives into CI log aggregation.
+package log
+
+import (
+	"os"
+
+	plog "github.com/phuslu/log"
+)
+
+var logger = plog.Logger{
+	Level:  plog.InfoLevel,
+	Writer: &plog.ConsoleWriter{Writer: os.Stderr},
+}
+
+// Configure sets the logger's output format ("text" or "json") and minimum
+// level from the resolved CLI flags. It's a no-op for unrecognized level
+// values so a typo in --log-level doesn't crash the run. silent raises the
+// effective level to error regardless of level, matching --silent's promise
+// to suppress non-error output.
+func Configure(format string, level string, silent bool) {
+	if format == "json" {
+		logger.Writer = &plog.IOWriter{Writer: os.Stderr}
+	} else {
+		logger.Writer = &plog.ConsoleWriter{Writer: os.Stderr}
+	}
+
+	switch level {
+	case "debug":
+		logger.Level = plog.DebugLevel
+	case "warn":
+		logger.Level = plog.WarnLevel
+	case "error":
+		logger.Level = plog.ErrorLevel
+	case "info", "":
+		logger.Level = plog.InfoLevel
+	}
+
+	if silent {
+		logger.Level = plog.ErrorLevel
+	}
+}
+
+func Debug() *plog.Entry { return logger.Debug() }
+func Info() *plog.Entry  { return logger.Info() }
+func Warn() *plog.Entry  { return logger.Warn() }
+func Error() *plog.Entry { return logger.Error() }
@@ -3,20 +3,26 @@ package main
 import (
 	"cli/allure"
 	"cli/config"
+	"cli/log"
 	"cli/request"
-	"fmt"
+	"context"
+	"errors"
 	"os"
-	"time"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
 	err := config.ReadFlags()
 	if err != nil {
-		fmt.Println("Error reading flags:\n", err.Error())
+		log.Error().Err(err).Msg("error reading flags")
 		os.Exit(7)
 	}
 
 	conf := config.GetConfig()
+	silent := conf.GetBool("SILENT")
+	log.Configure(conf.GetString("LOG_FORMAT"), conf.GetString("LOG_LEVEL"), silent)
+
 	host := conf.GetString("HOST")
 	login := conf.GetString("LOGIN")
 	password := conf.GetString("PASSWORD")
@@ -30,54 +36,94 @@ func main() {
 	osVersion := conf.GetString("OS_VERSION")
 	isolated := conf.GetString("ISOLATED")
 	systemImage := conf.GetString("SYSTEM_IMAGE")
+	noProgress := conf.GetBool("NO_PROGRESS")
+	force := conf.GetBool("FORCE")
+	include := conf.GetStringSlice("INCLUDE")
+	exclude := conf.GetStringSlice("EXCLUDE")
+	artifactOpts := allure.Options{
+		NoProgress: noProgress,
+		Silent:     silent,
+		Force:      force,
+		Include:    include,
+		Exclude:    exclude,
+	}
+
+	// Cancel artifact downloads cleanly on SIGINT/SIGTERM instead of leaving
+	// half-written files behind.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	if len(apiKey) == 0 {
 		token, err := request.Authorize(host, login, password)
 		if err != nil {
-			fmt.Println("Can't login: ", err.Error())
+			log.Error().Err(err).Msg("can't login")
 			os.Exit(6)
 		}
-		fmt.Println(time.Now().Format(time.Stamp), "Creating new run")
+		log.Info().Msg("creating new run")
 		runId, err := request.SendNewRun(host, token, app, testApp, commitName, commitLink, platform)
 		if err != nil {
-			fmt.Println(err.Error())
+			log.Error().Err(err).Msg("failed to create run")
 			os.Exit(5)
 		}
 		go request.Subscribe(token, runId)
 
 		state, err := request.WaitRunForEnd(host, runId, token)
+		var artifactErr error
 		if len(allureOutput) > 0 {
-			allure.GetArtifacts(host, token, runId, allureOutput)
+			artifactErr = allure.GetArtifacts(ctx, token, runId, allureOutput, artifactOpts)
 		}
 		if err != nil {
-			fmt.Println(err.Error())
+			log.Error().Err(err).Str("run_id", runId).Msg("run failed")
 			os.Exit(4)
 		}
 		if state != "passed" {
 			os.Exit(3)
 		}
+		if artifactErr != nil {
+			exitForArtifactError(artifactErr, runId)
+		}
 	} else {
 		jwtToken, err := request.RequestJwtToken(host, apiKey)
 		if err != nil {
-			fmt.Println(err)
+			log.Error().Err(err).Msg("failed to request JWT token")
 			return
 		}
 		runId, err := request.SendNewRunWithKey(host, apiKey, app, testApp, commitName, commitLink, platform, osVersion, systemImage, isolated)
 		if err != nil {
-			fmt.Println(err.Error())
+			log.Error().Err(err).Msg("failed to create run")
 			os.Exit(5)
 		}
 		go request.Subscribe(jwtToken, runId)
 		state, err := request.WaitRunForEndWithApiKey(host, runId, apiKey)
+		var artifactErr error
 		if len(allureOutput) > 0 {
-			allure.GetArtifacts(host, jwtToken, runId, allureOutput)
+			artifactErr = allure.GetArtifacts(ctx, jwtToken, runId, allureOutput, artifactOpts)
 		}
 		if err != nil {
-			fmt.Println(err.Error())
+			log.Error().Err(err).Str("run_id", runId).Msg("run failed")
 			os.Exit(4)
 		}
 		if state != "passed" {
 			os.Exit(3)
 		}
+		if artifactErr != nil {
+			exitForArtifactError(artifactErr, runId)
+		}
+	}
+}
+
+// exitForArtifactError logs an artifact download failure and exits with a
+// code identifying its cause. Callers only reach this once the run itself
+// has already been confirmed to pass, so a flaky artifact download can't
+// mask or override the test result's own exit code.
+func exitForArtifactError(err error, runId string) {
+	log.Error().Err(err).Str("run_id", runId).Msg("failed to download artifacts")
+	switch {
+	case errors.Is(err, request.ErrArtifactNotFound):
+		os.Exit(8)
+	case errors.Is(err, request.ErrRetryExhausted):
+		os.Exit(9)
+	default:
+		os.Exit(4)
 	}
 }
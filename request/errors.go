@@ -0,0 +1,14 @@
+package request
+
+import "errors"
+
+// Sentinel errors so callers can branch on failure type with errors.Is
+// instead of string-matching or exiting on an opaque code.
+var (
+	// ErrArtifactNotFound is returned when the API reports 404 for an
+	// artifact or folder lookup.
+	ErrArtifactNotFound = errors.New("artifact not found")
+	// ErrRetryExhausted is returned when a Pacer gives up after its
+	// configured number of attempts.
+	ErrRetryExhausted = errors.New("retry attempts exhausted")
+)
@@ -0,0 +1,169 @@
+package request
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Pacer throttles and retries outbound API calls with exponential backoff,
+// modeled on rclone's lib/pacer: the sleep interval grows on failure and
+// decays back toward the minimum on success, so a single flaky response
+// doesn't leave every later call throttled.
+type Pacer struct {
+	mu            sync.Mutex
+	minSleep      time.Duration
+	maxSleep      time.Duration
+	decayConstant uint
+	maxRetries    int
+	sleepTime     time.Duration
+	explicitSleep bool
+}
+
+// NewPacer builds a Pacer bounded by [minSleep, maxSleep]. decayConstant
+// controls how fast the sleep interval grows on failure and shrinks on
+// success (sleep <<= decayConstant / sleep >>= decayConstant). maxRetries
+// caps the total number of attempts made by Call.
+func NewPacer(minSleep, maxSleep time.Duration, decayConstant uint, maxRetries int) *Pacer {
+	return &Pacer{
+		minSleep:      minSleep,
+		maxSleep:      maxSleep,
+		decayConstant: decayConstant,
+		maxRetries:    maxRetries,
+		sleepTime:     minSleep,
+	}
+}
+
+// Call invokes fn, retrying while it reports retry=true, up to maxRetries
+// attempts. fn's err is returned directly once it reports retry=false; if
+// retries are exhausted while it's still asking for more, the last error is
+// wrapped in ErrRetryExhausted so callers can tell the two cases apart. The
+// backoff sleep between attempts observes ctx, so a cancelled ctx interrupts
+// a pending retry instead of leaving the caller blocked for up to maxSleep.
+func (p *Pacer) Call(ctx context.Context, fn func() (retry bool, err error)) error {
+	var err error
+	for attempt := 0; attempt < p.maxRetries; attempt++ {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var retry bool
+		retry, err = fn()
+		if !retry {
+			p.shrinkSleep()
+			return err
+		}
+		if !p.consumeExplicitSleep() {
+			p.growSleep()
+		}
+		select {
+		case <-time.After(p.sleep()):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrRetryExhausted, err)
+	}
+	return ErrRetryExhausted
+}
+
+// SetSleep overrides the current sleep interval, used to honor a server's
+// Retry-After header instead of our own backoff estimate. Call skips its next
+// growSleep so this exact value isn't immediately multiplied away.
+func (p *Pacer) SetSleep(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if d > p.maxSleep {
+		d = p.maxSleep
+	}
+	p.sleepTime = d
+	p.explicitSleep = true
+}
+
+// consumeExplicitSleep reports whether SetSleep was called since the last
+// growSleep/consumeExplicitSleep, clearing the flag so it only suppresses
+// growth once.
+func (p *Pacer) consumeExplicitSleep() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.explicitSleep {
+		p.explicitSleep = false
+		return true
+	}
+	return false
+}
+
+func (p *Pacer) sleep() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.sleepTime
+}
+
+func (p *Pacer) growSleep() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sleepTime <<= p.decayConstant
+	if p.sleepTime > p.maxSleep {
+		p.sleepTime = p.maxSleep
+	}
+}
+
+func (p *Pacer) shrinkSleep() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sleepTime >>= p.decayConstant
+	if p.sleepTime < p.minSleep {
+		p.sleepTime = p.minSleep
+	}
+}
+
+// ShouldRetryHTTP classifies a round trip for retry purposes: network errors,
+// 408, 425, 429 and any 5xx are considered transient.
+func ShouldRetryHTTP(resp *http.Response, err error) bool {
+	if err != nil {
+		var netErr net.Error
+		if ok := asNetError(err, &netErr); ok {
+			return true
+		}
+		return true
+	}
+	if resp == nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooEarly, http.StatusTooManyRequests:
+		return true
+	}
+	return resp.StatusCode >= http.StatusInternalServerError
+}
+
+func asNetError(err error, target *net.Error) bool {
+	ne, ok := err.(net.Error)
+	if ok {
+		*target = ne
+	}
+	return ok
+}
+
+// RetryAfter parses a Retry-After response header (seconds or HTTP-date),
+// returning 0 if the header is absent or unparseable.
+func RetryAfter(resp *http.Response) time.Duration {
+	if resp == nil {
+		return 0
+	}
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
@@ -0,0 +1,187 @@
+package request
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestPacerGrowAndShrinkSleep(t *testing.T) {
+	p := NewPacer(10*time.Millisecond, 80*time.Millisecond, 1, 8)
+
+	p.growSleep()
+	if got := p.sleep(); got != 20*time.Millisecond {
+		t.Fatalf("sleep after one growSleep = %v, want %v", got, 20*time.Millisecond)
+	}
+	p.growSleep()
+	if got := p.sleep(); got != 40*time.Millisecond {
+		t.Fatalf("sleep after two growSleep = %v, want %v", got, 40*time.Millisecond)
+	}
+
+	p.growSleep()
+	p.growSleep()
+	if got := p.sleep(); got != 80*time.Millisecond {
+		t.Fatalf("sleep should cap at maxSleep, got %v", got)
+	}
+
+	p.shrinkSleep()
+	if got := p.sleep(); got != 40*time.Millisecond {
+		t.Fatalf("sleep after shrinkSleep = %v, want %v", got, 40*time.Millisecond)
+	}
+
+	for i := 0; i < 10; i++ {
+		p.shrinkSleep()
+	}
+	if got := p.sleep(); got != 10*time.Millisecond {
+		t.Fatalf("sleep should floor at minSleep, got %v", got)
+	}
+}
+
+func TestPacerSetSleepSuppressesNextGrow(t *testing.T) {
+	p := NewPacer(10*time.Millisecond, time.Second, 2, 8)
+
+	p.SetSleep(300 * time.Millisecond)
+	if got := p.sleep(); got != 300*time.Millisecond {
+		t.Fatalf("sleep after SetSleep = %v, want %v", got, 300*time.Millisecond)
+	}
+
+	if !p.consumeExplicitSleep() {
+		t.Fatal("consumeExplicitSleep should report true right after SetSleep")
+	}
+	if p.consumeExplicitSleep() {
+		t.Fatal("consumeExplicitSleep should only report true once")
+	}
+}
+
+func TestPacerSetSleepClampsToMaxSleep(t *testing.T) {
+	p := NewPacer(10*time.Millisecond, time.Second, 2, 8)
+
+	p.SetSleep(5 * time.Second)
+	if got := p.sleep(); got != time.Second {
+		t.Fatalf("SetSleep should clamp to maxSleep, got %v", got)
+	}
+}
+
+func TestPacerCallRetriesUntilSuccess(t *testing.T) {
+	p := NewPacer(time.Millisecond, 5*time.Millisecond, 1, 5)
+
+	attempts := 0
+	err := p.Call(context.Background(), func() (bool, error) {
+		attempts++
+		if attempts < 3 {
+			return true, errors.New("transient")
+		}
+		return false, nil
+	})
+	if err != nil {
+		t.Fatalf("Call returned error after eventual success: %v", err)
+	}
+	if attempts != 3 {
+		t.Fatalf("attempts = %d, want 3", attempts)
+	}
+}
+
+func TestPacerCallReturnsErrRetryExhausted(t *testing.T) {
+	p := NewPacer(time.Millisecond, 2*time.Millisecond, 1, 3)
+
+	attempts := 0
+	err := p.Call(context.Background(), func() (bool, error) {
+		attempts++
+		return true, errors.New("always fails")
+	})
+	if !errors.Is(err, ErrRetryExhausted) {
+		t.Fatalf("Call error = %v, want wrapped ErrRetryExhausted", err)
+	}
+	if attempts != 3 {
+		t.Fatalf("attempts = %d, want maxRetries 3", attempts)
+	}
+}
+
+func TestPacerCallStopsOnCancelledContext(t *testing.T) {
+	p := NewPacer(50*time.Millisecond, time.Second, 1, 8)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := p.Call(ctx, func() (bool, error) {
+		t.Fatal("fn should not be invoked once ctx is already cancelled")
+		return false, nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Call error = %v, want context.Canceled", err)
+	}
+}
+
+func TestPacerCallInterruptsBackoffSleep(t *testing.T) {
+	p := NewPacer(time.Hour, time.Hour, 1, 8)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- p.Call(ctx, func() (bool, error) {
+			return true, errors.New("keep retrying")
+		})
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Call error = %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Call did not observe ctx cancellation during backoff sleep")
+	}
+}
+
+func TestShouldRetryHTTP(t *testing.T) {
+	cases := []struct {
+		name string
+		resp *http.Response
+		err  error
+		want bool
+	}{
+		{name: "network error", err: errors.New("dial tcp: timeout"), want: true},
+		{name: "nil response no error", want: true},
+		{name: "429 too many requests", resp: &http.Response{StatusCode: http.StatusTooManyRequests}, want: true},
+		{name: "500 internal server error", resp: &http.Response{StatusCode: http.StatusInternalServerError}, want: true},
+		{name: "200 ok", resp: &http.Response{StatusCode: http.StatusOK}, want: false},
+		{name: "404 not found", resp: &http.Response{StatusCode: http.StatusNotFound}, want: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := ShouldRetryHTTP(tc.resp, tc.err); got != tc.want {
+				t.Errorf("ShouldRetryHTTP() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	cases := []struct {
+		name   string
+		header string
+		want   time.Duration
+	}{
+		{name: "absent header", want: 0},
+		{name: "seconds", header: "120", want: 120 * time.Second},
+		{name: "unparseable", header: "not-a-valid-value", want: 0},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{}}
+			if tc.header != "" {
+				resp.Header.Set("Retry-After", tc.header)
+			}
+			if got := RetryAfter(resp); got != tc.want {
+				t.Errorf("RetryAfter() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package request
+
+import (
+	"cli/log"
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// defaultPacer paces every outbound call to the testwise.pro API so a
+// single flaky 5xx or dropped connection doesn't abort an otherwise healthy
+// run.
+var defaultPacer = NewPacer(500*time.Millisecond, 30*time.Second, 2, 8)
+
+// SendGetRequest issues an authenticated GET, retrying transient failures
+// through defaultPacer. ctx cancels both an in-flight request and any
+// pending retry backoff.
+func SendGetRequest(ctx context.Context, url string, token string) (*http.Response, error) {
+	resp, err := doPacedRequest(ctx, http.MethodGet, url, token, nil)
+	if err != nil {
+		log.Error().Err(err).Str("url", url).Msg("get request failed")
+		return nil, fmt.Errorf("get %s: %w", url, err)
+	}
+	return resp, nil
+}
+
+// SendHeadRequest issues an authenticated HEAD, retrying transient failures
+// through defaultPacer. Used to check a remote artifact's size/ETag without
+// downloading its body.
+func SendHeadRequest(ctx context.Context, url string, token string) (*http.Response, error) {
+	resp, err := doPacedRequest(ctx, http.MethodHead, url, token, nil)
+	if err != nil {
+		log.Error().Err(err).Str("url", url).Msg("head request failed")
+		return nil, fmt.Errorf("head %s: %w", url, err)
+	}
+	return resp, nil
+}
+
+// SendRangeGetRequest issues an authenticated GET with a "Range: bytes=N-"
+// header so a partially downloaded file can resume instead of starting over.
+func SendRangeGetRequest(ctx context.Context, url string, token string, startByte int64) (*http.Response, error) {
+	headers := map[string]string{"Range": fmt.Sprintf("bytes=%d-", startByte)}
+	resp, err := doPacedRequest(ctx, http.MethodGet, url, token, headers)
+	if err != nil {
+		log.Error().Err(err).Str("url", url).Int64("start_byte", startByte).Msg("ranged get request failed")
+		return nil, fmt.Errorf("ranged get %s: %w", url, err)
+	}
+	return resp, nil
+}
+
+func doPacedRequest(ctx context.Context, method, url, token string, headers map[string]string) (*http.Response, error) {
+	var resp *http.Response
+	err := defaultPacer.Call(ctx, func() (bool, error) {
+		req, err := http.NewRequestWithContext(ctx, method, url, nil)
+		if err != nil {
+			return false, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+
+		r, doErr := http.DefaultClient.Do(req)
+		if ShouldRetryHTTP(r, doErr) {
+			if wait := RetryAfter(r); wait > 0 {
+				defaultPacer.SetSleep(wait)
+			}
+			if r != nil {
+				r.Body.Close()
+			}
+			return true, doErr
+		}
+		if doErr != nil {
+			return false, doErr
+		}
+		if r.StatusCode == http.StatusNotFound {
+			r.Body.Close()
+			return false, ErrArtifactNotFound
+		}
+		resp = r
+		return false, nil
+	})
+	return resp, err
+}